@@ -0,0 +1,108 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newAuthTestClient starts a server that records the Authorization header
+// (and Basic-auth credentials, if any) it received, and returns a client
+// built with opts against it.
+func newAuthTestClient(t *testing.T, opts *ClientOptions) (client *KamailioJsonRpc, gotAuthHeader *string, gotBasicUser *string) {
+	t.Helper()
+	gotAuthHeader = new(string)
+	gotBasicUser = new(string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*gotAuthHeader = r.Header.Get("Authorization")
+		if user, _, ok := r.BasicAuth(); ok {
+			*gotBasicUser = user
+		}
+		var req KamJsonRpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		result := json.RawMessage(`"pong"`)
+		json.NewEncoder(w).Encode(KamJsonRpcResponse{Jsonrpc: "2.0", Id: req.Id, Result: &result})
+	}))
+	t.Cleanup(srv.Close)
+	client, err := NewKamailioJsonRpcWithOptions(srv.URL, opts)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpcWithOptions: %v", err)
+	}
+	return client, gotAuthHeader, gotBasicUser
+}
+
+func TestSetAuthPrefersBasicAuthOverTokenSourceAndBearer(t *testing.T) {
+	client, gotAuthHeader, gotBasicUser := newAuthTestClient(t, &ClientOptions{
+		BasicAuth:   &BasicAuth{User: "alice", Pass: "secret"},
+		BearerToken: "static-token",
+		TokenSource: func(ctx context.Context) (string, error) { return "rotated-token", nil },
+	})
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if *gotBasicUser != "alice" {
+		t.Errorf("basic auth user = %q, want %q", *gotBasicUser, "alice")
+	}
+	if *gotAuthHeader != "" && *gotBasicUser == "" {
+		t.Errorf("expected BasicAuth to win, got Authorization header %q", *gotAuthHeader)
+	}
+}
+
+func TestSetAuthPrefersTokenSourceOverBearerToken(t *testing.T) {
+	client, gotAuthHeader, _ := newAuthTestClient(t, &ClientOptions{
+		BearerToken: "static-token",
+		TokenSource: func(ctx context.Context) (string, error) { return "rotated-token", nil },
+	})
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if want := "Bearer rotated-token"; *gotAuthHeader != want {
+		t.Errorf("Authorization header = %q, want %q", *gotAuthHeader, want)
+	}
+}
+
+func TestSetAuthFallsBackToBearerToken(t *testing.T) {
+	client, gotAuthHeader, _ := newAuthTestClient(t, &ClientOptions{
+		BearerToken: "static-token",
+	})
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if want := "Bearer static-token"; *gotAuthHeader != want {
+		t.Errorf("Authorization header = %q, want %q", *gotAuthHeader, want)
+	}
+}
+
+func TestSetAuthSendsNoHeaderWithoutCredentials(t *testing.T) {
+	client, gotAuthHeader, _ := newAuthTestClient(t, &ClientOptions{})
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if *gotAuthHeader != "" {
+		t.Errorf("Authorization header = %q, want empty", *gotAuthHeader)
+	}
+}
+
+func TestSetAuthTokenSourceErrorAbortsCall(t *testing.T) {
+	client, _, _ := newAuthTestClient(t, &ClientOptions{
+		TokenSource: func(ctx context.Context) (string, error) {
+			return "", fmt.Errorf("token fetch failed")
+		},
+	})
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected Call to fail when TokenSource errors")
+	}
+}
@@ -0,0 +1,124 @@
+package kamjsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// BatchCall describes one call within a CallBatch request.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	// Notification marks this call as a JSON-RPC notification: it is sent
+	// without an id and Kamailio sends no reply for it. The corresponding
+	// BatchResult is always empty.
+	Notification bool
+}
+
+// BatchResult carries the outcome of one BatchCall, in the same order as the
+// calls passed to CallBatch.
+type BatchResult struct {
+	Result json.RawMessage
+	Error  *KamError
+}
+
+// batchJsonRpcRequest mirrors KamJsonRpcRequest but omits Id for
+// notifications, per the JSON-RPC 2.0 batch spec.
+type batchJsonRpcRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	Id      *uint64       `json:"id,omitempty"`
+}
+
+// CallBatch sends calls as a single JSON-RPC 2.0 batch request and
+// demultiplexes the responses back into the caller-supplied order. Kamailio
+// may reply out of order, or may reply with a single error object instead of
+// an array if it failed to parse the batch as a whole; CallBatch handles
+// both cases. Notifications never populate a BatchResult.
+func (self *KamailioJsonRpc) CallBatch(ctx context.Context, calls []BatchCall) ([]BatchResult, error) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+
+	reqs := make([]batchJsonRpcRequest, len(calls))
+	idToIndex := make(map[uint64]int, len(calls))
+
+	self.mutex.Lock()
+	for i, c := range calls {
+		br := batchJsonRpcRequest{Jsonrpc: "2.0", Method: c.Method, Params: []interface{}{}}
+		if argSlice, isSlice := c.Params.([]string); isSlice {
+			br.Params = make([]interface{}, len(argSlice))
+			for idx, val := range argSlice {
+				br.Params[idx] = val
+			}
+		} else if c.Params != nil {
+			br.Params = []interface{}{c.Params}
+		}
+		if !c.Notification {
+			id := self.id
+			self.id += 1
+			br.Id = &id
+			idToIndex[id] = i
+		}
+		reqs[i] = br
+	}
+	self.mutex.Unlock()
+
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	self.dmutex.Lock()
+	writeDone := self.writeDone
+	readDone := self.readDone
+	self.dmutex.Unlock()
+
+	respBody, statusCode, err := self.postJSON(ctx, body, writeDone, readDone)
+	if err != nil {
+		return nil, err
+	}
+
+	// An all-notification batch expects no reply at all: Kamailio sends an
+	// empty body rather than "[]" or a single object.
+	if trimmed := bytes.TrimSpace(respBody); len(trimmed) == 0 {
+		if len(idToIndex) == 0 {
+			return make([]BatchResult, len(calls)), nil
+		}
+		if statusCode > 299 {
+			return nil, fmt.Errorf("Unexpected status code received: %d", statusCode)
+		}
+		return nil, fmt.Errorf("empty batch response for %d pending call(s)", len(idToIndex))
+	}
+
+	var single KamJsonRpcResponse
+	if err := json.Unmarshal(respBody, &single); err == nil && single.Jsonrpc != "" {
+		if single.Error != nil {
+			return nil, fmt.Errorf("batch request rejected: %s", single.Error.Message)
+		}
+	}
+
+	var responses []KamJsonRpcResponse
+	if err := json.Unmarshal(respBody, &responses); err != nil {
+		if statusCode > 299 {
+			return nil, fmt.Errorf("Unexpected status code received: %d", statusCode)
+		}
+		return nil, fmt.Errorf("unparsable batch response: %w", err)
+	}
+
+	results := make([]BatchResult, len(calls))
+	for _, r := range responses {
+		idx, ok := idToIndex[r.Id]
+		if !ok {
+			continue
+		}
+		if r.Result != nil {
+			results[idx].Result = *r.Result
+		}
+		results[idx].Error = r.Error
+	}
+	return results, nil
+}
@@ -0,0 +1,172 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *KamailioJsonRpc {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+	return client
+}
+
+// decodeBatchRequest reads the incoming batch array off the wire, returning
+// each element's id (nil for notifications) alongside its method.
+func decodeBatchRequest(t *testing.T, r *http.Request) []batchJsonRpcRequest {
+	t.Helper()
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading request body: %v", err)
+	}
+	var reqs []batchJsonRpcRequest
+	if err := json.Unmarshal(raw, &reqs); err != nil {
+		t.Fatalf("decoding batch request: %v", err)
+	}
+	return reqs
+}
+
+func TestCallBatchParameterlessCallSendsEmptyArray(t *testing.T) {
+	var rawBody []byte
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		rawBody, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("reading request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK) // all-notification batch: no body expected back
+	})
+
+	_, err := client.CallBatch(context.Background(), []BatchCall{
+		{Method: "uac.reg_reload", Notification: true},
+	})
+	if err != nil {
+		t.Fatalf("CallBatch: %v", err)
+	}
+
+	if strings.Contains(string(rawBody), `"params":null`) {
+		t.Fatalf("wire request sent params:null, which is invalid JSON-RPC 2.0: %s", rawBody)
+	}
+	if !strings.Contains(string(rawBody), `"params":[]`) {
+		t.Errorf("expected a parameterless call to send params:[], got: %s", rawBody)
+	}
+}
+
+func TestCallBatchDemuxOutOfOrderAndPartial(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		reqs := decodeBatchRequest(t, r)
+
+		// Reply out of order, and skip the notification's id (it has none)
+		// as well as one call's reply entirely, to emulate a server that
+		// dropped a response.
+		var responses []KamJsonRpcResponse
+		for i := len(reqs) - 1; i >= 0; i-- {
+			if reqs[i].Id == nil {
+				continue
+			}
+			if reqs[i].Method == "ul.dump" {
+				continue // simulate a dropped reply
+			}
+			result := json.RawMessage(fmt.Sprintf(`"%s-result"`, reqs[i].Method))
+			responses = append(responses, KamJsonRpcResponse{
+				Jsonrpc: "2.0", Id: *reqs[i].Id, Result: &result,
+			})
+		}
+		body, _ := json.Marshal(responses)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+
+	calls := []BatchCall{
+		{Method: "core.echo"},
+		{Method: "uac.reg_reload", Notification: true},
+		{Method: "ul.dump"},
+		{Method: "domain.reload"},
+	}
+	results, err := client.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatalf("CallBatch: %v", err)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results, want %d", len(results), len(calls))
+	}
+	if got := string(results[0].Result); got != `"core.echo-result"` {
+		t.Errorf("results[0] = %s, want core.echo-result", got)
+	}
+	if results[1].Result != nil || results[1].Error != nil {
+		t.Errorf("notification result should stay empty, got %+v", results[1])
+	}
+	if results[2].Result != nil || results[2].Error != nil {
+		t.Errorf("dropped reply should stay empty, got %+v", results[2])
+	}
+	if got := string(results[3].Result); got != `"domain.reload-result"` {
+		t.Errorf("results[3] = %s, want domain.reload-result", got)
+	}
+}
+
+func TestCallBatchAllNotificationsNoBody(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		decodeBatchRequest(t, r)
+		w.WriteHeader(http.StatusOK) // no body: Kamailio sends nothing back
+	})
+
+	calls := []BatchCall{
+		{Method: "uac.reg_reload", Notification: true},
+		{Method: "uac.reg_refresh", Notification: true},
+	}
+	results, err := client.CallBatch(context.Background(), calls)
+	if err != nil {
+		t.Fatalf("CallBatch: %v", err)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results, want %d", len(results), len(calls))
+	}
+	for i, r := range results {
+		if r.Result != nil || r.Error != nil {
+			t.Errorf("results[%d] should be empty, got %+v", i, r)
+		}
+	}
+}
+
+func TestCallBatchStatusErrorOnUnparsableBody(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		decodeBatchRequest(t, r)
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("Bad Gateway"))
+	})
+
+	_, err := client.CallBatch(context.Background(), []BatchCall{{Method: "core.echo"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "502") {
+		t.Errorf("error %q should mention the 502 status code", err)
+	}
+}
+
+func TestCallBatchSingleErrorObject(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		decodeBatchRequest(t, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":0,"error":{"code":-32700,"message":"Parse error"}}`))
+	})
+
+	_, err := client.CallBatch(context.Background(), []BatchCall{{Method: "core.echo"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "Parse error") {
+		t.Errorf("error %q should mention the server's message", err)
+	}
+}
@@ -2,20 +2,19 @@
 Released under MIT License <http://www.opensource.org/licenses/mit-license.php
 Copyright (C) ITsysCOM GmbH. All Rights Reserved.
 
-Provides simple Kamailio JSON-RPC over HTTP communication.
+Provides simple Kamailio JSON-RPC communication over HTTP, Unix socket, or
+raw TCP transports.
 */
 
 package kamjsonrpc
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"sync"
+	"time"
 )
 
 const (
@@ -43,20 +42,157 @@ type KamJsonRpcResponse struct {
 }
 
 func NewKamailioJsonRpc(url string, skipTlsVerify bool) (*KamailioJsonRpc, error) {
-	client := &http.Client{Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTlsVerify}}}
-	return &KamailioJsonRpc{url: url, client: client, mutex: new(sync.Mutex)}, nil
+	return NewKamailioJsonRpcWithOptions(url, &ClientOptions{SkipTlsVerify: skipTlsVerify})
+}
+
+// NewKamailioJsonRpcWithOptions builds a KamailioJsonRpc with explicit
+// options, such as a RetryPolicy. The transport is picked from rawUrl's
+// scheme: "http"/"https" (the default) uses HTTP, "unix"/"unixgram" dial a
+// Kamailio jsonrpcs Unix socket, and "tcp" dials it over raw TCP. Pass nil
+// for opts to get the same defaults as NewKamailioJsonRpc(url, false).
+func NewKamailioJsonRpcWithOptions(rawUrl string, opts *ClientOptions) (*KamailioJsonRpc, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	transport, err := newTransport(rawUrl, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &KamailioJsonRpc{
+		url:         rawUrl,
+		transport:   transport,
+		mutex:       new(sync.Mutex),
+		dmutex:      new(sync.Mutex),
+		retryPolicy: opts.RetryPolicy,
+	}, nil
 }
 
 type KamailioJsonRpc struct {
-	url    string
-	client *http.Client
-	id     uint64
-	mutex  *sync.Mutex
+	url       string
+	transport Transport
+	id        uint64
+	mutex     *sync.Mutex
+
+	// retryPolicy is nil by default, meaning no retries (the pre-existing
+	// behavior): a failed Call returns immediately.
+	retryPolicy *RetryPolicy
+
+	// deadline state, guarded by dmutex
+	dmutex        *sync.Mutex
+	writeDeadline time.Time
+	readDeadline  time.Time
+	writeTimer    *time.Timer
+	readTimer     *time.Timer
+	writeDone     chan struct{}
+	readDone      chan struct{}
+}
+
+// SetWriteDeadline bounds the time allowed for each subsequent call's
+// transport round trip; since a round trip is a single operation, this
+// covers sending the request and reading the whole response, just like
+// SetReadDeadline. A zero time.Time clears the deadline.
+func (self *KamailioJsonRpc) SetWriteDeadline(t time.Time) error {
+	self.dmutex.Lock()
+	defer self.dmutex.Unlock()
+	if self.writeTimer != nil {
+		self.writeTimer.Stop()
+	}
+	self.writeDeadline = t
+	done := make(chan struct{})
+	self.writeDone = done
+	if !t.IsZero() {
+		if d := time.Until(t); d <= 0 {
+			close(done)
+		} else {
+			self.writeTimer = time.AfterFunc(d, func() { close(done) })
+		}
+	}
+	return nil
 }
 
-// Generic function to remotely call a method and pass the parameters
+// SetReadDeadline bounds the time allowed for each subsequent call's
+// transport round trip, from sending the request to reading the whole
+// response; it is not limited to the read phase. A zero time.Time clears
+// the deadline.
+func (self *KamailioJsonRpc) SetReadDeadline(t time.Time) error {
+	self.dmutex.Lock()
+	defer self.dmutex.Unlock()
+	if self.readTimer != nil {
+		self.readTimer.Stop()
+	}
+	self.readDeadline = t
+	done := make(chan struct{})
+	self.readDone = done
+	if !t.IsZero() {
+		if d := time.Until(t); d <= 0 {
+			close(done)
+		} else {
+			self.readTimer = time.AfterFunc(d, func() { close(done) })
+		}
+	}
+	return nil
+}
+
+// SetDeadline is a shorthand for calling both SetWriteDeadline and
+// SetReadDeadline with the same value.
+func (self *KamailioJsonRpc) SetDeadline(t time.Time) error {
+	if err := self.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return self.SetReadDeadline(t)
+}
+
+// Call is the non-context variant of CallContext, kept for backwards
+// compatibility. It is equivalent to CallContext(context.Background(), ...).
 func (self *KamailioJsonRpc) Call(serviceMethod string, args interface{}, reply *json.RawMessage) error {
+	return self.CallContext(context.Background(), serviceMethod, args, reply)
+}
+
+// CallContext remotely calls a method and passes the parameters, honoring
+// ctx, any deadlines set via SetReadDeadline/SetWriteDeadline, and the
+// client's retry policy (see RetryPolicy).
+func (self *KamailioJsonRpc) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply *json.RawMessage) error {
+	self.dmutex.Lock()
+	writeDone := self.writeDone
+	readDone := self.readDone
+	self.dmutex.Unlock()
+
+	policy := self.retryPolicy
+	attempts := 1
+	if policy != nil && policy.MaxAttempts > attempts {
+		attempts = policy.MaxAttempts
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepBackoff(ctx, policy, attempt); err != nil {
+				return err
+			}
+		}
+		kamResponse, statusCode, err := self.doCall(ctx, serviceMethod, args, writeDone, readDone)
+		if err == nil {
+			*reply = *kamResponse.Result
+			return nil
+		}
+		lastErr = err
+		var kamErr *KamError
+		if kamResponse != nil {
+			kamErr = kamResponse.Error
+		}
+		if policy == nil || !policy.retryable(statusCode, err, kamErr) {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// doCall performs a single Transport round trip for serviceMethod/args,
+// without retrying. It returns the decoded response (if any), the status
+// code reported by postJSON (0 if the request never got a response), and an
+// error describing
+// why the attempt failed, if any.
+func (self *KamailioJsonRpc) doCall(ctx context.Context, serviceMethod string, args interface{}, writeDone, readDone chan struct{}) (*KamJsonRpcResponse, int, error) {
 	self.mutex.Lock()
 	reqId := self.id
 	self.id += 1
@@ -72,47 +208,93 @@ func (self *KamailioJsonRpc) Call(serviceMethod string, args interface{}, reply
 	}
 	body, err := json.Marshal(req)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	resp, err := self.client.Post(self.url, "application/json", bytes.NewBuffer(body))
+
+	respBody, statusCode, err := self.postJSON(ctx, body, writeDone, readDone)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+		return nil, statusCode, err
 	}
 	var kamResponse KamJsonRpcResponse
 	if err = json.Unmarshal(respBody, &kamResponse); err != nil {
-		return err
+		return nil, statusCode, err
 	}
 	if kamResponse.Error != nil {
-		return errors.New(kamResponse.Error.Message)
+		return &kamResponse, statusCode, errors.New(kamResponse.Error.Message)
 	}
-	if resp.StatusCode > 299 {
-		return fmt.Errorf("Unexpected status code received: %d", resp.StatusCode)
+	if statusCode > 299 {
+		return &kamResponse, statusCode, fmt.Errorf("Unexpected status code received: %d", statusCode)
 	}
 	if kamResponse.Id != reqId {
-		return fmt.Errorf("Unsynchronized request, had: %d, received: %d", reqId, kamResponse.Id)
+		return &kamResponse, statusCode, fmt.Errorf("Unsynchronized request, had: %d, received: %d", reqId, kamResponse.Id)
 	}
-	*reply = *kamResponse.Result
-	return nil
+	return &kamResponse, statusCode, nil
+}
+
+// postJSON hands body to the configured Transport, honoring writeDone/
+// readDone deadline signals (see SetWriteDeadline/SetReadDeadline) in
+// addition to ctx. Since a Transport round trip is a single operation, both
+// deadlines bound the whole exchange. It returns the raw response body and
+// a status code: the HTTP status of a non-2xx HTTP response, 200 on success
+// for any transport, and 0 if the round trip failed outright.
+func (self *KamailioJsonRpc) postJSON(ctx context.Context, body []byte, writeDone, readDone chan struct{}) ([]byte, int, error) {
+	rtCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if writeDone != nil || readDone != nil {
+		// writeDone/readDone are nil-safe in a select: a nil channel case
+		// simply never fires.
+		go func() {
+			select {
+			case <-writeDone:
+				cancel()
+			case <-readDone:
+				cancel()
+			case <-rtCtx.Done():
+			}
+		}()
+		// A deadline that has already passed cancels the round trip before
+		// it starts, rather than racing the goroutine above.
+		select {
+		case <-writeDone:
+			cancel()
+		case <-readDone:
+			cancel()
+		default:
+		}
+	}
+
+	respBody, err := self.transport.RoundTrip(rtCtx, body)
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Body, statusErr.StatusCode, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return respBody, 200, nil
 }
 
 // Add inidividual methods over the generic one
 
 func (self *KamailioJsonRpc) CoreEcho(params []string, reply *[]string) error {
+	return self.CoreEchoContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) CoreEchoContext(ctx context.Context, params []string, reply *[]string) error {
 	var rplRaw json.RawMessage
-	if err := self.Call("core.echo", params, &rplRaw); err != nil {
+	if err := self.CallContext(ctx, "core.echo", params, &rplRaw); err != nil {
 		return err
 	}
 	return json.Unmarshal(rplRaw, reply)
 }
 
 func (self *KamailioJsonRpc) UacRegEnable(params []string, reply *string) error {
+	return self.UacRegEnableContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UacRegEnableContext(ctx context.Context, params []string, reply *string) error {
 	var regRaw json.RawMessage
-	if err := self.Call("uac.reg_enable", params, &regRaw); err != nil {
+	if err := self.CallContext(ctx, "uac.reg_enable", params, &regRaw); err != nil {
 		return err
 	}
 	*reply = OK
@@ -120,8 +302,12 @@ func (self *KamailioJsonRpc) UacRegEnable(params []string, reply *string) error
 }
 
 func (self *KamailioJsonRpc) UacRegDisable(params []string, reply *string) error {
+	return self.UacRegDisableContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UacRegDisableContext(ctx context.Context, params []string, reply *string) error {
 	var regRaw json.RawMessage
-	if err := self.Call("uac.reg_disable", params, &regRaw); err != nil {
+	if err := self.CallContext(ctx, "uac.reg_disable", params, &regRaw); err != nil {
 		return err
 	}
 	*reply = OK
@@ -129,8 +315,12 @@ func (self *KamailioJsonRpc) UacRegDisable(params []string, reply *string) error
 }
 
 func (self *KamailioJsonRpc) UacRegReload(params []string, reply *string) error {
+	return self.UacRegReloadContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UacRegReloadContext(ctx context.Context, params []string, reply *string) error {
 	var regRaw json.RawMessage
-	if err := self.Call("uac.reg_reload", params, &regRaw); err != nil {
+	if err := self.CallContext(ctx, "uac.reg_reload", params, &regRaw); err != nil {
 		return err
 	}
 	*reply = OK
@@ -138,8 +328,12 @@ func (self *KamailioJsonRpc) UacRegReload(params []string, reply *string) error
 }
 
 func (self *KamailioJsonRpc) UacRegRefresh(params []string, reply *string) error {
+	return self.UacRegRefreshContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UacRegRefreshContext(ctx context.Context, params []string, reply *string) error {
 	var regRaw json.RawMessage
-	if err := self.Call("uac.reg_refresh", params, &regRaw); err != nil {
+	if err := self.CallContext(ctx, "uac.reg_refresh", params, &regRaw); err != nil {
 		return err
 	}
 	*reply = OK
@@ -147,16 +341,24 @@ func (self *KamailioJsonRpc) UacRegRefresh(params []string, reply *string) error
 }
 
 func (self *KamailioJsonRpc) UacRegInfo(params []string, reply *RegistrationInfo) error {
+	return self.UacRegInfoContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UacRegInfoContext(ctx context.Context, params []string, reply *RegistrationInfo) error {
 	var regRaw json.RawMessage
-	if err := self.Call("uac.reg_info", params, &regRaw); err != nil {
+	if err := self.CallContext(ctx, "uac.reg_info", params, &regRaw); err != nil {
 		return err
 	}
 	return json.Unmarshal(regRaw, reply)
 }
 
 func (self *KamailioJsonRpc) DomainReload(params []string, reply *string) error {
+	return self.DomainReloadContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) DomainReloadContext(ctx context.Context, params []string, reply *string) error {
 	var response json.RawMessage
-	if err := self.Call("domain.reload", params, &response); err != nil {
+	if err := self.CallContext(ctx, "domain.reload", params, &response); err != nil {
 		return err
 	}
 	*reply = OK
@@ -164,8 +366,12 @@ func (self *KamailioJsonRpc) DomainReload(params []string, reply *string) error
 }
 
 func (self *KamailioJsonRpc) UsrlocDump(params []string, reply *ULDump) error {
+	return self.UsrlocDumpContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UsrlocDumpContext(ctx context.Context, params []string, reply *ULDump) error {
 	var ulRaw json.RawMessage
-	if err := self.Call("ul.dump", params, &ulRaw); err != nil {
+	if err := self.CallContext(ctx, "ul.dump", params, &ulRaw); err != nil {
 		return err
 	}
 	return json.Unmarshal(ulRaw, reply)
@@ -173,8 +379,12 @@ func (self *KamailioJsonRpc) UsrlocDump(params []string, reply *ULDump) error {
 
 //func (self *KamailioJsonRpc) UsrlocLookup(params []string, reply *json.RawMessage) error {
 func (self *KamailioJsonRpc) UsrlocLookup(params []string, reply *ULSingle) error {
+	return self.UsrlocLookupContext(context.Background(), params, reply)
+}
+
+func (self *KamailioJsonRpc) UsrlocLookupContext(ctx context.Context, params []string, reply *ULSingle) error {
 	var ulRaw json.RawMessage
-	if err := self.Call("ul.lookup", params, &ulRaw); err != nil {
+	if err := self.CallContext(ctx, "ul.lookup", params, &ulRaw); err != nil {
 		return err
 	}
 	return json.Unmarshal(ulRaw, reply)
@@ -0,0 +1,137 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func slowEchoServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req KamJsonRpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		time.Sleep(delay)
+		result := json.RawMessage(`"pong"`)
+		json.NewEncoder(w).Encode(KamJsonRpcResponse{Jsonrpc: "2.0", Id: req.Id, Result: &result})
+	}))
+}
+
+func TestCallContextAbortsOnCancellation(t *testing.T) {
+	srv := slowEchoServer(t, time.Second)
+	defer srv.Close()
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	var reply json.RawMessage
+	err = client.CallContext(ctx, "core.echo", []string{"hi"}, &reply)
+	if err == nil {
+		t.Fatal("expected CallContext to fail when ctx is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("CallContext took %s, should have aborted on cancellation well before the server's 1s delay", elapsed)
+	}
+}
+
+func TestSetReadDeadlineAbortsSlowCall(t *testing.T) {
+	srv := slowEchoServer(t, time.Second)
+	defer srv.Close()
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+	if err := client.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("SetReadDeadline: %v", err)
+	}
+
+	start := time.Now()
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected Call to fail once the read deadline fires")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Call took %s, should have aborted on the read deadline well before the server's 1s delay", elapsed)
+	}
+}
+
+func TestSetWriteDeadlineAbortsSlowCall(t *testing.T) {
+	srv := slowEchoServer(t, time.Second)
+	defer srv.Close()
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+	if err := client.SetWriteDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("SetWriteDeadline: %v", err)
+	}
+
+	start := time.Now()
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected Call to fail once the write deadline fires")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Call took %s, should have aborted on the write deadline well before the server's 1s delay", elapsed)
+	}
+}
+
+func TestSetDeadlineClearsOnZeroValue(t *testing.T) {
+	srv := slowEchoServer(t, 0)
+	defer srv.Close()
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+
+	if err := client.SetDeadline(time.Now().Add(-time.Second)); err == nil {
+		// Setting a deadline in the past should make the very next call fail.
+	}
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected Call to fail with an already-past deadline")
+	}
+
+	// Clearing the deadline (zero value) should let subsequent calls through.
+	if err := client.SetDeadline(time.Time{}); err != nil {
+		t.Fatalf("SetDeadline(zero): %v", err)
+	}
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call after clearing deadline: %v", err)
+	}
+	if string(reply) != `"pong"` {
+		t.Errorf("reply = %s, want \"pong\"", reply)
+	}
+}
+
+func TestCoreEchoContextPropagatesCancellation(t *testing.T) {
+	srv := slowEchoServer(t, time.Second)
+	defer srv.Close()
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var reply []string
+	start := time.Now()
+	if err := client.CoreEchoContext(ctx, []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected CoreEchoContext to fail once ctx times out")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("CoreEchoContext took %s, should have aborted on ctx timeout", elapsed)
+	}
+}
@@ -0,0 +1,57 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"crypto/x509"
+)
+
+// ClientOptions configures a KamailioJsonRpc built via
+// NewKamailioJsonRpcWithOptions.
+type ClientOptions struct {
+	// SkipTlsVerify disables TLS certificate verification, equivalent to the
+	// skipTlsVerify argument of NewKamailioJsonRpc.
+	SkipTlsVerify bool
+
+	// RetryPolicy configures retries for transient failures. Nil (the
+	// default) disables retrying, matching the behavior of
+	// NewKamailioJsonRpc.
+	RetryPolicy *RetryPolicy
+
+	// BasicAuth, if set, sends HTTP Basic credentials with every request.
+	// Ignored by non-HTTP transports.
+	BasicAuth *BasicAuth
+
+	// BearerToken, if set, is sent as a static "Authorization: Bearer"
+	// header with every request. Ignored if BasicAuth or TokenSource is
+	// set, or by non-HTTP transports.
+	BearerToken string
+
+	// TokenSource, if set, is called before every request to obtain the
+	// bearer token, so short-lived OIDC/JWT tokens can be rotated without
+	// rebuilding the client. Takes precedence over BearerToken. Ignored (and
+	// never called) if BasicAuth is set, or by non-HTTP transports.
+	TokenSource func(ctx context.Context) (string, error)
+
+	// ClientCertFile and ClientKeyFile, if both set, are loaded as the
+	// client certificate for mTLS. Ignored by non-HTTP transports.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// CAFile, if set, is read as a PEM bundle of CAs trusted to verify the
+	// server certificate, in addition to RootCAs. Ignored by non-HTTP
+	// transports.
+	CAFile string
+
+	// RootCAs, if set, is used as the base pool of CAs trusted to verify the
+	// server certificate; CAFile's certificates are appended to a copy of
+	// it, leaving the caller's pool unchanged. Nil
+	// means start from an empty pool unless CAFile is also unset, in which
+	// case the system pool is used. Ignored by non-HTTP transports.
+	RootCAs *x509.CertPool
+}
+
+// BasicAuth carries HTTP Basic auth credentials for ClientOptions.
+type BasicAuth struct {
+	User string
+	Pass string
+}
@@ -0,0 +1,96 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how CallContext (and therefore Call) retries
+// transient failures. The zero value makes no retries; DefaultRetryPolicy
+// returns a policy with sane defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a single Call,
+	// including the first one. Values <= 1 disable retrying.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential delay between attempts. Jitter is
+	// applied after the cap, so the actual delay may exceed it by up to
+	// Jitter*MaxBackoff.
+	MaxBackoff time.Duration
+	// Jitter is the fraction (0..1) of the computed backoff that is randomly
+	// added or subtracted, to avoid thundering-herd retries.
+	Jitter float64
+	// Retryable decides whether a failed attempt should be retried. statusCode
+	// is 0 if no HTTP response was received. kamErr is non-nil only when
+	// Kamailio returned a well-formed JSON-RPC application error, which is
+	// never retried regardless of what Retryable returns. If Retryable is
+	// nil, DefaultRetryable is used.
+	Retryable func(statusCode int, err error, kamErr *KamError) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with sane defaults for a Kamailio
+// deployment fronted by a load balancer: a handful of attempts with
+// exponential backoff, retrying network errors and 5xx responses only.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// retryable reports whether the given attempt outcome should be retried.
+// A JSON-RPC application error (kamErr != nil) is never retryable: it is a
+// well-formed answer from Kamailio, not a transient failure.
+func (p *RetryPolicy) retryable(statusCode int, err error, kamErr *KamError) bool {
+	if kamErr != nil {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(statusCode, err, kamErr)
+	}
+	return DefaultRetryable(statusCode, err, kamErr)
+}
+
+// DefaultRetryable retries network errors (statusCode == 0) and 5xx HTTP
+// responses, and never retries JSON-RPC application errors.
+func DefaultRetryable(statusCode int, err error, kamErr *KamError) bool {
+	if kamErr != nil {
+		return false
+	}
+	if statusCode == 0 {
+		return err != nil
+	}
+	return statusCode >= 500
+}
+
+// sleepBackoff waits the backoff delay for the given attempt (1-based retry
+// count), honoring ctx cancellation. attempt==1 is the delay before the
+// second overall try.
+func sleepBackoff(ctx context.Context, policy *RetryPolicy, attempt int) error {
+	if policy == nil {
+		return nil
+	}
+	backoff := policy.InitialBackoff << uint(attempt-1)
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	if policy.Jitter > 0 {
+		delta := float64(backoff) * policy.Jitter
+		backoff += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	if backoff <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
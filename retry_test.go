@@ -0,0 +1,122 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDefaultRetryable(t *testing.T) {
+	cases := []struct {
+		name       string
+		statusCode int
+		err        error
+		kamErr     *KamError
+		want       bool
+	}{
+		{"network error", 0, context.DeadlineExceeded, nil, true},
+		{"502", 502, nil, nil, true},
+		{"503", 503, nil, nil, true},
+		{"404 not retried", 404, nil, nil, false},
+		{"200 with no error not retried", 200, nil, nil, false},
+		{"application error never retried even with 5xx", 503, nil, &KamError{Code: -32000, Message: "boom"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := DefaultRetryable(c.statusCode, c.err, c.kamErr); got != c.want {
+				t.Errorf("DefaultRetryable(%d, %v, %v) = %v, want %v", c.statusCode, c.err, c.kamErr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetryableOverridesDefault(t *testing.T) {
+	policy := &RetryPolicy{
+		Retryable: func(statusCode int, err error, kamErr *KamError) bool {
+			return statusCode == 418 // only retry teapots
+		},
+	}
+	if policy.retryable(503, nil, nil) {
+		t.Error("custom Retryable should have vetoed the default 5xx rule")
+	}
+	if !policy.retryable(418, nil, nil) {
+		t.Error("custom Retryable should have allowed its own rule")
+	}
+	// A JSON-RPC application error is never retried, even if the custom
+	// predicate would otherwise say yes.
+	if policy.retryable(418, nil, &KamError{Message: "boom"}) {
+		t.Error("application errors must never be retried")
+	}
+}
+
+func TestCallRetriesTransientFailuresThenSucceeds(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req KamJsonRpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		result := json.RawMessage(`"ok"`)
+		json.NewEncoder(w).Encode(KamJsonRpcResponse{Jsonrpc: "2.0", Id: req.Id, Result: &result})
+	}))
+	defer srv.Close()
+
+	client, err := NewKamailioJsonRpcWithOptions(srv.URL, &ClientOptions{
+		RetryPolicy: &RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpcWithOptions: %v", err)
+	}
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if string(reply) != `"ok"` {
+		t.Errorf("reply = %s, want \"ok\"", reply)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("server saw %d attempts, want 3", got)
+	}
+}
+
+func TestCallDefaultsToZeroRetries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, err := NewKamailioJsonRpc(srv.URL, false)
+	if err != nil {
+		t.Fatalf("NewKamailioJsonRpc: %v", err)
+	}
+
+	var reply json.RawMessage
+	if err := client.Call("core.echo", []string{"hi"}, &reply); err == nil {
+		t.Fatal("expected an error from a 503 response")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("server saw %d attempts, want exactly 1 (no retries by default)", got)
+	}
+}
+
+func TestSleepBackoffHonorsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy := &RetryPolicy{InitialBackoff: time.Hour}
+	start := time.Now()
+	if err := sleepBackoff(ctx, policy, 1); err == nil {
+		t.Fatal("expected sleepBackoff to return the context error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepBackoff took %s, should have returned immediately on a cancelled context", elapsed)
+	}
+}
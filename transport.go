@@ -0,0 +1,324 @@
+package kamjsonrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Transport abstracts the wire protocol used to exchange JSON-RPC request
+// and response bodies with Kamailio. RoundTrip sends requestBody and
+// returns the raw response body.
+type Transport interface {
+	RoundTrip(ctx context.Context, requestBody []byte) ([]byte, error)
+}
+
+// HTTPStatusError is returned by the HTTP transport when the server answers
+// with a non-2xx status code. Body still carries whatever the server sent,
+// since Kamailio may put a JSON-RPC error object in it.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code received: %d", e.StatusCode)
+}
+
+// newTransport picks a Transport for rawUrl based on its scheme:
+// "http"/"https" (and no scheme, for backwards compatibility) use HTTP,
+// "unix"/"unixgram" dial a Kamailio jsonrpcs Unix socket, and "tcp" dials
+// raw TCP.
+func newTransport(rawUrl string, opts *ClientOptions) (Transport, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "", "http", "https":
+		return NewHTTPTransport(rawUrl, opts)
+	case "unix":
+		return NewUnixTransport(u.Path), nil
+	case "unixgram":
+		return newUnixTransport(u.Path, "unixgram"), nil
+	case "tcp":
+		return NewTCPTransport(u.Host), nil
+	default:
+		return nil, fmt.Errorf("kamjsonrpc: unsupported transport scheme %q", u.Scheme)
+	}
+}
+
+type httpTransport struct {
+	url         string
+	client      *http.Client
+	basicAuth   *BasicAuth
+	bearerToken string
+	tokenSource func(ctx context.Context) (string, error)
+}
+
+// NewHTTPTransport builds a Transport that POSTs the JSON-RPC body to url
+// over HTTP(S), using opts to configure TLS verification, mTLS, and
+// authentication.
+func NewHTTPTransport(url string, opts *ClientOptions) (Transport, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	tlsConfig, err := buildTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return &httpTransport{
+		url:         url,
+		client:      client,
+		basicAuth:   opts.BasicAuth,
+		bearerToken: opts.BearerToken,
+		tokenSource: opts.TokenSource,
+	}, nil
+}
+
+// buildTLSConfig assembles a tls.Config from opts: InsecureSkipVerify,
+// an optional client certificate for mTLS, and an optional CA pool.
+func buildTLSConfig(opts *ClientOptions) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: opts.SkipTlsVerify}
+
+	if opts.ClientCertFile != "" && opts.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kamjsonrpc: loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.CAFile != "" {
+		var pool *x509.CertPool
+		if opts.RootCAs != nil {
+			pool = opts.RootCAs.Clone()
+		} else {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kamjsonrpc: reading CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kamjsonrpc: no certificates found in %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	} else if opts.RootCAs != nil {
+		config.RootCAs = opts.RootCAs
+	}
+
+	return config, nil
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, requestBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := t.setAuth(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode > 299 {
+		return body, &HTTPStatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return body, nil
+}
+
+// setAuth injects the Authorization header for the configured scheme, if
+// any. TokenSource is re-invoked on every call so short-lived OIDC/JWT
+// tokens stay fresh.
+func (t *httpTransport) setAuth(ctx context.Context, req *http.Request) error {
+	switch {
+	case t.basicAuth != nil:
+		req.SetBasicAuth(t.basicAuth.User, t.basicAuth.Pass)
+	case t.tokenSource != nil:
+		token, err := t.tokenSource(ctx)
+		if err != nil {
+			return fmt.Errorf("kamjsonrpc: fetching token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case t.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	}
+	return nil
+}
+
+// unixTransport exchanges JSON-RPC messages over a Kamailio jsonrpcs Unix
+// socket, per-call: dial, write the request, read the reply, close. Stream
+// ("unix") messages are newline-framed; "unixgram" sends each request as a
+// single unframed datagram.
+type unixTransport struct {
+	path    string
+	network string
+}
+
+// NewUnixTransport builds a Transport that dials the Unix stream socket at
+// path for every call.
+func NewUnixTransport(path string) Transport {
+	return newUnixTransport(path, "unix")
+}
+
+func newUnixTransport(path, network string) Transport {
+	return &unixTransport{path: path, network: network}
+}
+
+func (t *unixTransport) RoundTrip(ctx context.Context, requestBody []byte) ([]byte, error) {
+	if t.network == "unixgram" {
+		return t.roundTripDatagram(ctx, requestBody)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, t.network, t.path)
+	if err != nil {
+		return nil, err
+	}
+	return exchangeFramed(ctx, conn, requestBody)
+}
+
+// roundTripDatagram sends requestBody as a single unixgram packet and waits
+// for the reply packet. Unlike DialContext for stream sockets, dialing a
+// unixgram socket without binding a local address leaves the client
+// anonymous: Kamailio's sendto() has no peer address to reply to and the
+// call hangs until a deadline fires. So an ephemeral local socket is bound
+// first and removed once the exchange completes.
+func (t *unixTransport) roundTripDatagram(ctx context.Context, requestBody []byte) ([]byte, error) {
+	localAddr, err := ephemeralUnixgramAddr()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(localAddr.Name)
+
+	d := net.Dialer{LocalAddr: localAddr}
+	conn, err := d.DialContext(ctx, "unixgram", t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := watchContextCancel(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(requestBody); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return buf[:n], nil
+}
+
+// ephemeralUnixgramAddr reserves a unique path in the system temp dir for
+// use as a unixgram socket's local (bind) address. The caller must remove
+// the path once done with the socket.
+func ephemeralUnixgramAddr() (*net.UnixAddr, error) {
+	f, err := ioutil.TempFile("", "kamjsonrpc-*.sock")
+	if err != nil {
+		return nil, err
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return nil, err
+	}
+	return &net.UnixAddr{Name: name, Net: "unixgram"}, nil
+}
+
+// tcpTransport exchanges newline-framed JSON-RPC messages over raw TCP,
+// per-call: dial, write the request, read the reply, close.
+type tcpTransport struct {
+	addr string
+}
+
+// NewTCPTransport builds a Transport that dials addr over TCP for every
+// call.
+func NewTCPTransport(addr string) Transport {
+	return &tcpTransport{addr: addr}
+}
+
+func (t *tcpTransport) RoundTrip(ctx context.Context, requestBody []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	return exchangeFramed(ctx, conn, requestBody)
+}
+
+// exchangeFramed writes requestBody newline-terminated to conn, half-closes
+// the write side so Kamailio sees end-of-request, then reads the reply
+// until EOF. conn is closed before returning.
+func exchangeFramed(ctx context.Context, conn net.Conn, requestBody []byte) ([]byte, error) {
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := watchContextCancel(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(append(requestBody, '\n')); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+	body, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return body, nil
+}
+
+// watchContextCancel arranges for conn's deadline to fire as soon as ctx is
+// done, so a blocked Write/Read returns instead of hanging forever when the
+// caller cancels ctx without ever setting an explicit deadline. The
+// returned stop func must be called once the exchange finishes to release
+// the watcher goroutine.
+func watchContextCancel(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ctxErr reports ctx.Err() if ctx is done, since a deadline forced by
+// watchContextCancel surfaces as a generic i/o timeout error rather than
+// the context error callers expect; otherwise it returns err unchanged.
+func ctxErr(ctx context.Context, err error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return err
+	}
+}
@@ -0,0 +1,152 @@
+package kamjsonrpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveUnixOnce accepts a single stream connection on path, reads whatever
+// is sent, and writes back reply.
+func serveUnixOnce(t *testing.T, path string, reply []byte) {
+	t.Helper()
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		conn.Read(buf)
+		conn.Write(reply)
+	}()
+}
+
+func TestUnixTransportRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kam.sock")
+	serveUnixOnce(t, path, []byte(`{"jsonrpc":"2.0","id":0,"result":"pong"}`))
+
+	transport := NewUnixTransport(path)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	got, err := transport.RoundTrip(ctx, []byte(`{"jsonrpc":"2.0","method":"core.echo","id":0}`))
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	want := `{"jsonrpc":"2.0","id":0,"result":"pong"}`
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// serveUnixgramOnce listens on path for a single datagram and replies to
+// whatever peer address it came from, proving the client bound a local
+// address the server could reply to.
+func serveUnixgramOnce(t *testing.T, path string, reply []byte) {
+	t.Helper()
+	addr, err := net.ResolveUnixAddr("unixgram", path)
+	if err != nil {
+		t.Fatalf("resolve unixgram addr: %v", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatalf("listen unixgram: %v", err)
+	}
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, peer, err := conn.ReadFromUnix(buf)
+		if err != nil {
+			t.Errorf("server ReadFromUnix: %v", err)
+			return
+		}
+		if peer == nil || peer.Name == "" {
+			t.Errorf("server got no usable peer address for a %d-byte datagram; client was not bound", n)
+			return
+		}
+		conn.WriteToUnix(reply, peer)
+	}()
+}
+
+func TestUnixgramTransportRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kam.sock")
+	serveUnixgramOnce(t, path, []byte(`{"jsonrpc":"2.0","id":0,"result":"pong"}`))
+
+	transport := newUnixTransport(path, "unixgram")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	got, err := transport.RoundTrip(ctx, []byte(`{"jsonrpc":"2.0","method":"core.echo","id":0}`))
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	want := `{"jsonrpc":"2.0","id":0,"result":"pong"}`
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// serveUnixSilently accepts a single connection on path and never writes a
+// reply, so callers must rely on ctx to unblock a read.
+func serveUnixSilently(t *testing.T, path string) {
+	t.Helper()
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-make(chan struct{}) // block until the test process tears down
+	}()
+}
+
+func TestUnixTransportRoundTripHonorsContextCancelWithoutDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kam.sock")
+	serveUnixSilently(t, path)
+
+	transport := NewUnixTransport(path)
+	ctx, cancel := context.WithCancel(context.Background()) // no deadline set
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := transport.RoundTrip(ctx, []byte(`{"jsonrpc":"2.0","method":"core.echo","id":0}`))
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond) // let RoundTrip block on the read
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("RoundTrip error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RoundTrip did not return after ctx was cancelled")
+	}
+}
+
+func TestEphemeralUnixgramAddrCleansUpBindPath(t *testing.T) {
+	addr, err := ephemeralUnixgramAddr()
+	if err != nil {
+		t.Fatalf("ephemeralUnixgramAddr: %v", err)
+	}
+	if _, err := os.Stat(addr.Name); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to not exist before bind, got err=%v", addr.Name, err)
+	}
+}
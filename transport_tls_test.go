@@ -0,0 +1,113 @@
+package kamjsonrpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// generateSelfSignedCert returns a PEM-encoded self-signed certificate and
+// its matching private key, for exercising certificate-loading code paths.
+// The chain is never actually validated by these tests.
+func generateSelfSignedCert(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"kamjsonrpc test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestBuildTLSConfigDoesNotMutateCallerRootCAs(t *testing.T) {
+	caPEM, _ := generateSelfSignedCert(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(caFile, caPEM, 0o644); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+
+	callerPool := x509.NewCertPool()
+	before := len(callerPool.Subjects())
+
+	_, err := buildTLSConfig(&ClientOptions{CAFile: caFile, RootCAs: callerPool})
+	if err != nil {
+		t.Fatalf("buildTLSConfig: %v", err)
+	}
+
+	if after := len(callerPool.Subjects()); after != before {
+		t.Errorf("caller's RootCAs pool was mutated in place: had %d subjects, now has %d", before, after)
+	}
+}
+
+func TestBuildTLSConfigLoadsClientCertAndCAFile(t *testing.T) {
+	certPEM, keyPEM := generateSelfSignedCert(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(certFile, certPEM, 0o644); err != nil {
+		t.Fatalf("writing cert file: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("writing key file: %v", err)
+	}
+	if err := ioutil.WriteFile(caFile, certPEM, 0o644); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+
+	config, err := buildTLSConfig(&ClientOptions{
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+		CAFile:         caFile,
+	})
+	if err != nil {
+		t.Fatalf("buildTLSConfig: %v", err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Errorf("got %d client certificates, want 1", len(config.Certificates))
+	}
+	if config.RootCAs == nil {
+		t.Error("expected RootCAs to be populated from CAFile")
+	}
+}
+
+func TestBuildTLSConfigErrorsOnBadPaths(t *testing.T) {
+	if _, err := buildTLSConfig(&ClientOptions{ClientCertFile: "/no/such/cert", ClientKeyFile: "/no/such/key"}); err == nil {
+		t.Error("expected an error for a missing client certificate")
+	}
+	if _, err := buildTLSConfig(&ClientOptions{CAFile: "/no/such/ca.pem"}); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+
+	badCAFile := filepath.Join(t.TempDir(), "bad-ca.pem")
+	if err := ioutil.WriteFile(badCAFile, []byte("not a cert"), 0o644); err != nil {
+		t.Fatalf("writing bad CA file: %v", err)
+	}
+	if _, err := buildTLSConfig(&ClientOptions{CAFile: badCAFile}); err == nil {
+		t.Error("expected an error for a CA file with no certificates")
+	}
+}